@@ -0,0 +1,164 @@
+// Copyright © 2016 Phil Estes <estesp@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+)
+
+// baselineComparison is one driver/command row of the --baseline report.
+type baselineComparison struct {
+	benchInfo    string
+	threads      int
+	command      string
+	avgDelta     float64 // percent change in avg latency, baseline -> current
+	throughput   float64 // ratio of current thread rate to baseline thread rate
+	memDelta     float64 // percent change in avg daemon mem
+	cpuDelta     float64 // ratio of current avg daemon CPU to baseline
+	regressed    bool
+	significance string
+}
+
+func loadBaseline(path string) (jsonOutput, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return jsonOutput{}, fmt.Errorf("error reading baseline file %q: %v", path, err)
+	}
+
+	var baseline jsonOutput
+	if err := json.Unmarshal(data, &baseline); err != nil {
+		return jsonOutput{}, fmt.Errorf("error unmarshaling baseline file %q: %v", path, err)
+	}
+
+	return baseline, nil
+}
+
+// findDriverRow looks up the row in out matching the given driver/thread
+// count combination.
+func findDriverRow(out jsonOutput, benchInfo string, threads int) (jsonDriverResult, bool) {
+	for _, row := range out.Drivers {
+		if row.BenchInfo == benchInfo && row.Threads == threads {
+			return row, true
+		}
+	}
+	return jsonDriverResult{}, false
+}
+
+// compareToBaseline builds a driver x command comparison between a
+// previously saved baseline result set and the current run, flagging a
+// regression when the new mean is above the baseline's confidence interval
+// or the percent increase in avg latency exceeds regressThreshold (a
+// threshold of 0 disables that check). Faster results are never flagged.
+func compareToBaseline(baseline, current jsonOutput, regressThreshold float64) []baselineComparison {
+	var comparisons []baselineComparison
+
+	for _, currentRow := range current.Drivers {
+		baselineRow, ok := findDriverRow(baseline, currentRow.BenchInfo, currentRow.Threads)
+		if !ok {
+			continue
+		}
+
+		// findDriverRow matched on Threads, so ThreadRates here holds
+		// only this row's own thread count (buildJSONOutput scopes it
+		// per row rather than returning the driver's full rate slice)
+		var baselineRate, currentRate float64
+		if len(baselineRow.ThreadRates) > 0 {
+			baselineRate = baselineRow.ThreadRates[0]
+		}
+		if len(currentRow.ThreadRates) > 0 {
+			currentRate = currentRow.ThreadRates[0]
+		}
+
+		var memDelta, cpuDelta float64
+		if baselineRow.Overhead != nil && currentRow.Overhead != nil {
+			memDelta = 100*getDelta(float64(baselineRow.Overhead.AvgMem), float64(currentRow.Overhead.AvgMem)) - 100
+			cpuDelta = getDelta(baselineRow.Overhead.AvgCPU, currentRow.Overhead.AvgCPU)
+		}
+
+		for _, currentCmd := range currentRow.Commands {
+			var baselineCmd *jsonCommandStats
+			for i := range baselineRow.Commands {
+				if baselineRow.Commands[i].Command == currentCmd.Command {
+					baselineCmd = &baselineRow.Commands[i]
+					break
+				}
+			}
+			if baselineCmd == nil {
+				continue
+			}
+
+			avgDelta := 100*getDelta(baselineCmd.Avg, currentCmd.Avg) - 100
+
+			// a regression means latency got worse (higher), never better;
+			// a mean below the baseline's CI or a negative delta is a
+			// speedup and must not fail the gate
+			outsideCI := currentCmd.Avg > baselineCmd.CIHigh
+			overThreshold := regressThreshold > 0 && avgDelta > regressThreshold
+
+			significance := "-"
+			regressed := false
+			switch {
+			case outsideCI && overThreshold:
+				significance = "outside baseline CI, over threshold"
+				regressed = true
+			case outsideCI:
+				significance = "outside baseline CI"
+				regressed = true
+			case overThreshold:
+				significance = "over threshold"
+				regressed = true
+			}
+
+			comparisons = append(comparisons, baselineComparison{
+				benchInfo:    currentRow.BenchInfo,
+				threads:      currentRow.Threads,
+				command:      currentCmd.Command,
+				avgDelta:     avgDelta,
+				throughput:   getDelta(baselineRate, currentRate),
+				memDelta:     memDelta,
+				cpuDelta:     cpuDelta,
+				regressed:    regressed,
+				significance: significance,
+			})
+		}
+	}
+
+	return comparisons
+}
+
+// outputBaselineComparison prints the driver x command comparison table
+// against the previously saved baseline, returning true if any regression
+// was flagged.
+func outputBaselineComparison(comparisons []baselineComparison) bool {
+	w := tabwriter.NewWriter(os.Stdout, 10, 4, 2, ' ', tabwriter.AlignRight)
+
+	fmt.Printf("\nBASELINE COMPARISON\n\n")
+	fmt.Fprintf(w, "Driver:Threads\tCommand\tAvg Δ%%\tThroughput x\tMem Δ%%\tCPU x\tRegression\t\n")
+
+	regressed := false
+	for _, c := range comparisons {
+		if c.regressed {
+			regressed = true
+		}
+		fmt.Fprintf(w, "%s:%d\t%s\t%+.2f%%\t%.2fx\t%+.2f%%\t%.2fx\t%s\t\n",
+			c.benchInfo, c.threads, c.command, c.avgDelta, c.throughput, c.memDelta, c.cpuDelta, c.significance)
+	}
+
+	w.Flush()
+	return regressed
+}
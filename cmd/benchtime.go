@@ -0,0 +1,140 @@
+// Copyright © 2016 Phil Estes <estesp@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/estesp/bucketbench/benches"
+)
+
+const (
+	benchtimeStartIterations = 10
+	benchtimeMaxGrowth       = 100
+	benchtimeMaxIterations   = 1 << 30
+)
+
+// benchTimeFlag implements pflag.Value and mirrors the format of Go's
+// `testing.benchTimeFlag`: either a plain duration ("10s", "500ms") or a
+// fixed iteration count given as "Nx" (e.g. "1000x").
+type benchTimeFlag struct {
+	d time.Duration
+	n int
+}
+
+func (f *benchTimeFlag) String() string {
+	if f.n > 0 {
+		return fmt.Sprintf("%dx", f.n)
+	}
+	return f.d.String()
+}
+
+func (f *benchTimeFlag) Set(s string) error {
+	if strings.HasSuffix(s, "x") {
+		n, err := strconv.ParseInt(strings.TrimSuffix(s, "x"), 10, 64)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("invalid iteration count %q; must be a positive integer followed by 'x'", s)
+		}
+		f.n = int(n)
+		f.d = 0
+		return nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return fmt.Errorf("invalid --benchtime %q; must be a duration (e.g. 10s) or a count (e.g. 1000x)", s)
+	}
+	f.d = d
+	f.n = 0
+	return nil
+}
+
+func (f *benchTimeFlag) Type() string {
+	return "benchtime"
+}
+
+// isSet reports whether --benchtime was given a value, as opposed to being
+// left at its unset zero value.
+func (f *benchTimeFlag) isSet() bool {
+	return f.n > 0 || f.d > 0
+}
+
+// benchAttempt runs a single benchmark pass with the given iteration count
+// and returns the resulting bench along with its elapsed duration.
+type benchAttempt func(iterations int) (benches.Bench, time.Duration, error)
+
+// runBenchtimeLoop implements duration-based auto-scaling iteration counts,
+// mirroring Go's `testing.benchTimeFlag`: when a fixed count is given it is
+// used as-is, otherwise the loop starts with a small iteration count, times
+// it, and estimates the count needed to fill the target duration, growing
+// by at most benchtimeMaxGrowth per step and rounding up to a "nice"
+// 1/2/5·10^k number, until the target duration is reached or the max
+// iteration cap is hit.
+func runBenchtimeLoop(bt benchTimeFlag, attempt benchAttempt) (iterations int, bench benches.Bench, elapsed time.Duration, err error) {
+	if bt.n > 0 {
+		bench, elapsed, err = attempt(bt.n)
+		return bt.n, bench, elapsed, err
+	}
+
+	target := bt.d
+	n := benchtimeStartIterations
+	for {
+		bench, elapsed, err = attempt(n)
+		if err != nil {
+			return n, nil, 0, err
+		}
+		if elapsed >= target || n >= benchtimeMaxIterations {
+			return n, bench, elapsed, nil
+		}
+
+		elapsedSec := elapsed.Seconds()
+		if elapsedSec <= 0 {
+			elapsedSec = 1e-9
+		}
+		next := float64(n) * target.Seconds() / elapsedSec
+		if maxNext := float64(n) * benchtimeMaxGrowth; next > maxNext {
+			next = maxNext
+		}
+
+		n = niceRoundUp(int(math.Ceil(next)))
+		if n <= 0 {
+			n = benchtimeStartIterations
+		}
+		if n > benchtimeMaxIterations {
+			n = benchtimeMaxIterations
+		}
+	}
+}
+
+// niceRoundUp rounds n up to the next "nice" number of the form
+// {1,2,5}·10^k, the same progression `go test -benchtime` uses when growing
+// b.N between runs.
+func niceRoundUp(n int) int {
+	if n <= 0 {
+		return 1
+	}
+
+	magnitude := math.Pow(10, math.Floor(math.Log10(float64(n))))
+	for _, step := range []float64{1, 2, 5, 10} {
+		if candidate := step * magnitude; candidate >= float64(n) {
+			return int(candidate)
+		}
+	}
+	return int(10 * magnitude)
+}
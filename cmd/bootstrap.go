@@ -0,0 +1,177 @@
+// Copyright © 2016 Phil Estes <estesp@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// bcaConfidenceInterval computes a non-parametric BCA (bias-corrected and
+// accelerated) bootstrap confidence interval for the mean of the given
+// samples. resamples controls how many bootstrap resamples are drawn, and
+// confidence is the desired interval width (e.g. 0.95 for a 95% CI).
+func bcaConfidenceInterval(samples []float64, resamples int, confidence float64) (low, high float64) {
+	n := len(samples)
+	if n < 2 || resamples < 1 {
+		return 0, 0
+	}
+
+	observedMean := mean(samples)
+
+	// draw R resamples with replacement of the same size as the observed
+	// sample, and compute the mean of each to build the bootstrap
+	// distribution
+	bootMeans := make([]float64, resamples)
+	resample := make([]float64, n)
+	for r := 0; r < resamples; r++ {
+		for i := 0; i < n; i++ {
+			resample[i] = samples[rand.Intn(n)]
+		}
+		bootMeans[r] = mean(resample)
+	}
+	sort.Float64s(bootMeans)
+
+	// bias-correction: z0 is the inverse normal CDF of the fraction of
+	// bootstrap means below the observed mean
+	below := 0
+	for _, m := range bootMeans {
+		if m < observedMean {
+			below++
+		}
+	}
+	fraction := float64(below) / float64(resamples)
+	// clamp away from 0/1 so invNormalCDF doesn't blow up
+	fraction = math.Max(1.0/float64(resamples+1), math.Min(fraction, float64(resamples)/float64(resamples+1)))
+	z0 := invNormalCDF(fraction)
+
+	// acceleration: jackknife estimate of skewness of the mean
+	a := jackknifeAcceleration(samples)
+
+	alpha := 1 - confidence
+	zLow := invNormalCDF(alpha / 2)
+	zHigh := invNormalCDF(1 - alpha/2)
+
+	adjust := func(z float64) float64 {
+		return normalCDF(z0 + (z0+z)/(1-a*(z0+z)))
+	}
+
+	lowPct := adjust(zLow)
+	highPct := adjust(zHigh)
+
+	low = quantile(bootMeans, lowPct)
+	high = quantile(bootMeans, highPct)
+	return low, high
+}
+
+func mean(samples []float64) float64 {
+	var sum float64
+	for _, s := range samples {
+		sum += s
+	}
+	return sum / float64(len(samples))
+}
+
+// jackknifeAcceleration computes the acceleration constant `a` for the BCA
+// interval using leave-one-out (jackknife) means of the sample.
+func jackknifeAcceleration(samples []float64) float64 {
+	n := len(samples)
+	jackMeans := make([]float64, n)
+	for i := range samples {
+		var sum float64
+		for j, s := range samples {
+			if j != i {
+				sum += s
+			}
+		}
+		jackMeans[i] = sum / float64(n-1)
+	}
+
+	jackMean := mean(jackMeans)
+
+	var num, denom float64
+	for _, jm := range jackMeans {
+		diff := jackMean - jm
+		num += diff * diff * diff
+		denom += diff * diff
+	}
+	if denom == 0 {
+		return 0
+	}
+	return num / (6 * math.Pow(denom, 1.5))
+}
+
+// quantile maps a percentile in [0,1] onto a sorted slice via linear
+// interpolation between the two nearest ranks.
+func quantile(sorted []float64, pct float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	if pct <= 0 {
+		return sorted[0]
+	}
+	if pct >= 1 {
+		return sorted[len(sorted)-1]
+	}
+	pos := pct * float64(len(sorted)-1)
+	lo := int(math.Floor(pos))
+	hi := int(math.Ceil(pos))
+	if lo == hi {
+		return sorted[lo]
+	}
+	frac := pos - float64(lo)
+	return sorted[lo]*(1-frac) + sorted[hi]*frac
+}
+
+// normalCDF returns the standard normal cumulative distribution function
+// at x.
+func normalCDF(x float64) float64 {
+	return 0.5 * math.Erfc(-x/math.Sqrt2)
+}
+
+// invNormalCDF returns the inverse of the standard normal CDF (the probit
+// function) using Acklam's rational approximation, accurate to about 1.15e-9.
+func invNormalCDF(p float64) float64 {
+	if p <= 0 {
+		return math.Inf(-1)
+	}
+	if p >= 1 {
+		return math.Inf(1)
+	}
+
+	// coefficients for the rational approximation
+	a := []float64{-3.969683028665376e+01, 2.209460984245205e+02, -2.759285104469687e+02, 1.383577518672690e+02, -3.066479806614716e+01, 2.506628277459239e+00}
+	b := []float64{-5.447609879822406e+01, 1.615858368580409e+02, -1.556989798598866e+02, 6.680131188771972e+01, -1.328068155288572e+01}
+	c := []float64{-7.784894002430293e-03, -3.223964580411365e-01, -2.400758277161838e+00, -2.549732539343734e+00, 4.374664141464968e+00, 2.938163982698783e+00}
+	d := []float64{7.784695709041462e-03, 3.224671290700398e-01, 2.445134137142996e+00, 3.754408661907416e+00}
+
+	const pLow = 0.02425
+	switch {
+	case p < pLow:
+		q := math.Sqrt(-2 * math.Log(p))
+		return (((((c[0]*q+c[1])*q+c[2])*q+c[3])*q+c[4])*q + c[5]) /
+			((((d[0]*q+d[1])*q+d[2])*q+d[3])*q + 1)
+	case p > 1-pLow:
+		q := math.Sqrt(-2 * math.Log(1-p))
+		return -(((((c[0]*q+c[1])*q+c[2])*q+c[3])*q+c[4])*q + c[5]) /
+			((((d[0]*q+d[1])*q+d[2])*q+d[3])*q + 1)
+	default:
+		q := p - 0.5
+		r := q * q
+		return (((((a[0]*r+a[1])*r+a[2])*r+a[3])*r+a[4])*r + a[5]) * q /
+			(((((b[0]*r+b[1])*r+b[2])*r+b[3])*r+b[4])*r + 1)
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright © 2016 Phil Estes <estesp@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"github.com/montanaflynn/stats"
+)
+
+// flakeRateStats computes, for each thread count position, the coefficient
+// of variation (stddev/mean) along with the min/max/median of threadRates
+// across the repeated --flake passes. All passes are expected to share the
+// same number of thread-count entries.
+func flakeRateStats(passes []benchResult) (cv, min, max, median []float64) {
+	width := len(passes[0].threadRates)
+	cv = make([]float64, width)
+	min = make([]float64, width)
+	max = make([]float64, width)
+	median = make([]float64, width)
+
+	for pos := 0; pos < width; pos++ {
+		var samples []float64
+		for _, pass := range passes {
+			if pos < len(pass.threadRates) {
+				samples = append(samples, pass.threadRates[pos])
+			}
+		}
+
+		mean, _ := stats.Mean(samples)
+		stddev, _ := stats.StandardDeviation(samples)
+		if mean != 0 {
+			cv[pos] = stddev / mean
+		}
+		min[pos], _ = stats.Min(samples)
+		max[pos], _ = stats.Max(samples)
+		median[pos], _ = stats.Median(samples)
+	}
+
+	return cv, min, max, median
+}
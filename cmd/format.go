@@ -0,0 +1,239 @@
+// Copyright © 2016 Phil Estes <estesp@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// jsonOutput is the top-level schema emitted by --output-format json; it
+// mirrors the tables produced by outputRunDetails.
+type jsonOutput struct {
+	Drivers []jsonDriverResult `json:"drivers"`
+}
+
+type jsonDriverResult struct {
+	BenchInfo   string             `json:"benchInfo"`
+	DriverInfo  string             `json:"driverInfo"`
+	Threads     int                `json:"threads"`
+	Iterations  int                `json:"iterations"`
+	ThreadRates []float64          `json:"threadRates"`
+	Commands    []jsonCommandStats `json:"commands"`
+	Overhead    *jsonOverheadStats `json:"overhead,omitempty"`
+	Flake       *jsonFlakeStats    `json:"flake,omitempty"`
+}
+
+// jsonFlakeStats reports rate stability across the repeated --flake passes
+// for this row's thread count.
+type jsonFlakeStats struct {
+	Runs   int     `json:"runs"`
+	CV     float64 `json:"cv"`
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	Median float64 `json:"median"`
+}
+
+type jsonCommandStats struct {
+	Command string  `json:"command"`
+	Min     float64 `json:"min"`
+	Max     float64 `json:"max"`
+	Avg     float64 `json:"avg"`
+	Median  float64 `json:"median"`
+	Stddev  float64 `json:"stddev"`
+	CILow   float64 `json:"ciLow"`
+	CIHigh  float64 `json:"ciHigh"`
+	Errors  int     `json:"errors"`
+}
+
+type jsonOverheadStats struct {
+	MinMem uint64  `json:"minMemMB"`
+	MaxMem uint64  `json:"maxMemMB"`
+	AvgMem uint64  `json:"avgMemMB"`
+	MinCPU float64 `json:"minCPUPercent"`
+	MaxCPU float64 `json:"maxCPUPercent"`
+	AvgCPU float64 `json:"avgCPUPercent"`
+}
+
+// buildJSONOutput collapses a benchResult slice down into the structured
+// schema shared by the json and csv output formats. Legacy mode results
+// (one entry per thread count) are flattened to one row per thread count,
+// while the default mode produces a single row per driver.
+func buildJSONOutput(results []benchResult, overhead bool, legacyMode bool) jsonOutput {
+	var out jsonOutput
+
+	for _, result := range results {
+		if result.name == limitBenchmarkName {
+			continue
+		}
+
+		rowCount := 1
+		if legacyMode {
+			rowCount = result.threads
+		}
+
+		for i := 0; i < rowCount; i++ {
+			threads := result.threads
+			threadRates := result.threadRates
+			iterations := result.iterations
+			if legacyMode {
+				threads = i + 1
+				threadRates = result.threadRates[i : i+1]
+				if i < len(result.iterationsPerThread) {
+					iterations = result.iterationsPerThread[i]
+				}
+			}
+
+			row := jsonDriverResult{
+				BenchInfo:   result.name,
+				DriverInfo:  result.driverInfo,
+				Threads:     threads,
+				Iterations:  iterations,
+				ThreadRates: threadRates,
+			}
+
+			cmdTimings := parseStats(result.statistics[i])
+			for _, command := range []string{"run", "pause", "resume", "stop", "delete"} {
+				if stats, ok := cmdTimings[command]; ok {
+					row.Commands = append(row.Commands, jsonCommandStats{
+						Command: command,
+						Min:     stats.min,
+						Max:     stats.max,
+						Avg:     stats.avg,
+						Median:  stats.median,
+						Stddev:  stats.stddev,
+						CILow:   stats.ciLow,
+						CIHigh:  stats.ciHigh,
+						Errors:  stats.errors,
+					})
+				}
+			}
+
+			if overhead {
+				m := parseMetrics(result.statistics[i])
+				row.Overhead = &jsonOverheadStats{
+					MinMem: m.minMem,
+					MaxMem: m.maxMem,
+					AvgMem: m.avgMem,
+					MinCPU: m.minCPU,
+					MaxCPU: m.maxCPU,
+					AvgCPU: m.avgCPU,
+				}
+			}
+
+			if result.flakeRuns > 1 && i < len(result.rateCV) {
+				row.Flake = &jsonFlakeStats{
+					Runs:   result.flakeRuns,
+					CV:     result.rateCV[i],
+					Min:    result.rateMin[i],
+					Max:    result.rateMax[i],
+					Median: result.rateMedian[i],
+				}
+			}
+
+			out.Drivers = append(out.Drivers, row)
+		}
+	}
+
+	return out
+}
+
+// writeOutput sends the rendered bytes to outputFile if set, otherwise to
+// stdout.
+func writeOutput(data []byte, outputFile string) error {
+	if outputFile == "" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(outputFile, data, 0644)
+}
+
+func outputRunDetailsJSON(results []benchResult, overhead bool, legacyMode bool, outputFile string) error {
+	out := buildJSONOutput(results, overhead, legacyMode)
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling JSON output: %v", err)
+	}
+	data = append(data, '\n')
+	return writeOutput(data, outputFile)
+}
+
+func outputRunDetailsCSV(results []benchResult, overhead bool, legacyMode bool, outputFile string) error {
+	out := buildJSONOutput(results, overhead, legacyMode)
+
+	var target *os.File
+	if outputFile == "" {
+		target = os.Stdout
+	} else {
+		f, err := os.Create(outputFile)
+		if err != nil {
+			return fmt.Errorf("error creating CSV output file %q: %v", outputFile, err)
+		}
+		defer f.Close()
+		target = f
+	}
+
+	w := csv.NewWriter(target)
+	defer w.Flush()
+
+	header := []string{"benchInfo", "driverInfo", "threads", "iterations", "command", "min", "max", "avg", "median", "stddev", "ciLow", "ciHigh", "errors"}
+	if overhead {
+		header = append(header, "minMemMB", "maxMemMB", "avgMemMB", "minCPUPercent", "maxCPUPercent", "avgCPUPercent")
+	}
+	if flakeRuns > 1 {
+		header = append(header, "rateCV", "rateMin", "rateMax", "rateMedian")
+	}
+	if err := w.Write(header); err != nil {
+		return err
+	}
+
+	for _, row := range out.Drivers {
+		for _, cmd := range row.Commands {
+			record := []string{
+				row.BenchInfo, row.DriverInfo,
+				fmt.Sprintf("%d", row.Threads), fmt.Sprintf("%d", row.Iterations),
+				cmd.Command,
+				fmt.Sprintf("%.4f", cmd.Min), fmt.Sprintf("%.4f", cmd.Max), fmt.Sprintf("%.4f", cmd.Avg),
+				fmt.Sprintf("%.4f", cmd.Median), fmt.Sprintf("%.4f", cmd.Stddev),
+				fmt.Sprintf("%.4f", cmd.CILow), fmt.Sprintf("%.4f", cmd.CIHigh), fmt.Sprintf("%d", cmd.Errors),
+			}
+			if overhead {
+				if row.Overhead != nil {
+					record = append(record,
+						fmt.Sprintf("%d", row.Overhead.MinMem), fmt.Sprintf("%d", row.Overhead.MaxMem), fmt.Sprintf("%d", row.Overhead.AvgMem),
+						fmt.Sprintf("%.2f", row.Overhead.MinCPU), fmt.Sprintf("%.2f", row.Overhead.MaxCPU), fmt.Sprintf("%.2f", row.Overhead.AvgCPU))
+				} else {
+					record = append(record, "", "", "", "", "", "")
+				}
+			}
+			if flakeRuns > 1 {
+				if row.Flake != nil {
+					record = append(record,
+						fmt.Sprintf("%.4f", row.Flake.CV), fmt.Sprintf("%.4f", row.Flake.Min),
+						fmt.Sprintf("%.4f", row.Flake.Max), fmt.Sprintf("%.4f", row.Flake.Median))
+				} else {
+					record = append(record, "", "", "", "")
+				}
+			}
+			if err := w.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,176 @@
+// Copyright © 2016 Phil Estes <estesp@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// profileManifest links the pprof snapshots collected for a single bench
+// run back to the driver, thread count, and run parameters that produced
+// them.
+type profileManifest struct {
+	Driver    string            `json:"driver"`
+	Threads   int               `json:"threads"`
+	StartedAt time.Time         `json:"startedAt"`
+	Duration  string            `json:"duration"`
+	Files     map[string]string `json:"files"`
+}
+
+// profileCollector drives pprof collection against a driver's debug
+// endpoint, given as a user-configured HTTP base address, for the span of a
+// single bench run. Profiles are fetched with plain HTTP GETs of
+// addr+"/debug/pprof/..."; unix socket endpoints are not supported.
+type profileCollector struct {
+	addr     string
+	outDir   string
+	types    []string
+	manifest profileManifest
+}
+
+func parseProfileTypes(s string) []string {
+	var types []string
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			types = append(types, t)
+		}
+	}
+	return types
+}
+
+// newProfileCollector returns a nil collector (and no error) when profiling
+// isn't configured; its methods do not accept a nil receiver, so callers
+// check for nil before using it. pass and attempt key the
+// output directory so repeated --flake passes and --benchtime growth steps
+// each get their own profile files instead of overwriting one another.
+func newProfileCollector(driverName string, threads, pass, attempt int, debugAddr string) (*profileCollector, error) {
+	if profileDir == "" || debugAddr == "" {
+		return nil, nil
+	}
+
+	outDir := filepath.Join(profileDir, driverName, fmt.Sprintf("%d", threads), fmt.Sprintf("pass%d", pass), fmt.Sprintf("attempt%d", attempt))
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return nil, fmt.Errorf("error creating profile output dir %q: %v", outDir, err)
+	}
+
+	return &profileCollector{
+		addr:   debugAddr,
+		outDir: outDir,
+		types:  parseProfileTypes(profileTypes),
+		manifest: profileManifest{
+			Driver:  driverName,
+			Threads: threads,
+			Files:   make(map[string]string),
+		},
+	}, nil
+}
+
+func (p *profileCollector) hasType(t string) bool {
+	for _, want := range p.types {
+		if want == t {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchProfile issues an HTTP GET against the driver's pprof endpoint and
+// saves the raw response body under name.
+func (p *profileCollector) fetchProfile(ctx context.Context, path, name string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.addr+path, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error fetching profile %q: %v", path, err)
+	}
+	defer resp.Body.Close()
+
+	dest := filepath.Join(p.outDir, name)
+	f, err := os.Create(dest)
+	if err != nil {
+		return fmt.Errorf("error creating profile file %q: %v", dest, err)
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		return fmt.Errorf("error writing profile file %q: %v", dest, err)
+	}
+
+	p.manifest.Files[name] = dest
+	return nil
+}
+
+// snapshotHeapAndGoroutine takes an instantaneous heap/goroutine/mutex
+// snapshot, tagging each file with the given suffix ("start" or "end").
+func (p *profileCollector) snapshotHeapAndGoroutine(ctx context.Context, suffix string) {
+	for _, t := range []string{"heap", "goroutine", "mutex"} {
+		if !p.hasType(t) {
+			continue
+		}
+		name := fmt.Sprintf("%s-%s.pprof", t, suffix)
+		if err := p.fetchProfile(ctx, fmt.Sprintf("/debug/pprof/%s", t), name); err != nil {
+			log.Warnf("profile: %v", err)
+		}
+	}
+}
+
+// collectCPUProfile blocks on the driver's /debug/pprof/profile endpoint
+// for the given duration, so it should be started in its own goroutine
+// alongside the bench run it profiles.
+func (p *profileCollector) collectCPUProfile(ctx context.Context, duration time.Duration) {
+	if !p.hasType("cpu") {
+		return
+	}
+	seconds := int(duration.Seconds())
+	if seconds < 1 {
+		seconds = 1
+	}
+	path := fmt.Sprintf("/debug/pprof/profile?seconds=%d", seconds)
+	if err := p.fetchProfile(ctx, path, "cpu.pprof"); err != nil {
+		log.Warnf("profile: %v", err)
+	}
+}
+
+// writeManifest records the run's start time and duration alongside the
+// profile files already collected, then serializes everything to
+// manifest.json in the collector's output directory.
+func (p *profileCollector) writeManifest(startedAt time.Time, duration time.Duration) error {
+	p.manifest.StartedAt = startedAt
+	p.manifest.Duration = duration.String()
+
+	data, err := json.MarshalIndent(p.manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling profile manifest: %v", err)
+	}
+
+	dest := filepath.Join(p.outDir, "manifest.json")
+	if err := os.WriteFile(dest, data, 0644); err != nil {
+		return fmt.Errorf("error writing profile manifest %q: %v", dest, err)
+	}
+	return nil
+}
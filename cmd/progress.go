@@ -0,0 +1,175 @@
+// Copyright © 2016 Phil Estes <estesp@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/estesp/bucketbench/benches"
+	isatty "github.com/mattn/go-isatty"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	progressBarWidth    = 30
+	progressTick        = 250 * time.Millisecond
+	progressLogInterval = 5 * time.Second
+	progressRateWindow  = 5 * time.Second
+)
+
+// progressReporter is implemented by benches.Bench implementations that
+// expose a live count of completed iterations. benches.Bench itself
+// doesn't declare this method, so callers type-assert for it and fall
+// back to periodic log lines when a driver doesn't support it.
+type progressReporter interface {
+	Progress() <-chan int
+}
+
+// runWithProgress runs bench.Run in the background while rendering live
+// progress fed by the bench's progress channel: a redrawn progress bar on
+// stderr when stdout is a TTY, or periodic log lines otherwise.
+func runWithProgress(ctx context.Context, bench benches.Bench, benchInfo string, threads, iterations int, duration time.Duration, commands []string) error {
+	total := threads * iterations
+
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- bench.Run(ctx, threads, iterations, duration, commands)
+	}()
+
+	// not every benches.Bench implementation reports progress; drivers
+	// that do so opt in by implementing progressReporter
+	var progressC <-chan int
+	if pr, ok := bench.(progressReporter); ok {
+		progressC = pr.Progress()
+	}
+
+	// without a real progress channel there's never a "completed" count to
+	// draw a bar from, so fall back to periodic log lines even in a TTY
+	// rather than rendering a bar stuck at 0% for the whole run
+	tty := isatty.IsTerminal(os.Stdout.Fd()) && progressC != nil
+
+	var (
+		completed int
+		lastLog   time.Time
+		rate      = newRollingRate(progressRateWindow)
+	)
+
+	render := func() {
+		rate.sample(completed)
+		switch {
+		case tty:
+			renderProgressBar(benchInfo, completed, total, rate.perSecond())
+		case time.Since(lastLog) >= progressLogInterval:
+			lastLog = time.Now()
+			log.Infof("%s: progress %d/%d (%.1f%%)", benchInfo, completed, total, percentComplete(completed, total))
+		}
+	}
+
+	ticker := time.NewTicker(progressTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case n, ok := <-progressC:
+			if !ok {
+				// the driver closed its progress channel; stop
+				// selecting on it and keep rendering from the
+				// ticker until Run returns
+				progressC = nil
+				continue
+			}
+			completed = n
+			render()
+		case <-ticker.C:
+			render()
+		case err := <-runErr:
+			if tty {
+				fmt.Fprintln(os.Stderr)
+			}
+			return err
+		}
+	}
+}
+
+// renderProgressBar redraws a single-line progress bar in place on stderr.
+func renderProgressBar(label string, completed, total int, ratePerSec float64) {
+	pct := percentComplete(completed, total)
+	filled := int(float64(progressBarWidth) * pct / 100)
+	if filled > progressBarWidth {
+		filled = progressBarWidth
+	}
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", progressBarWidth-filled)
+
+	eta := "?"
+	if ratePerSec > 0 && completed < total {
+		remaining := float64(total-completed) / ratePerSec
+		eta = time.Duration(remaining * float64(time.Second)).Round(time.Second).String()
+	}
+
+	fmt.Fprintf(os.Stderr, "\r%s [%s] %3.0f%% (%d/%d) eta %s", label, bar, pct, completed, total, eta)
+}
+
+func percentComplete(completed, total int) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return float64(completed) / float64(total) * 100
+}
+
+// rollingRate tracks a (timestamp, count) sample history within a fixed
+// window and reports the rate of change across that window, so the
+// progress bar's ETA reflects recent throughput rather than the run's
+// average since start.
+type rollingRate struct {
+	window  time.Duration
+	samples []rateSample
+}
+
+type rateSample struct {
+	at    time.Time
+	count int
+}
+
+func newRollingRate(window time.Duration) *rollingRate {
+	return &rollingRate{window: window}
+}
+
+func (r *rollingRate) sample(count int) {
+	now := time.Now()
+	r.samples = append(r.samples, rateSample{at: now, count: count})
+
+	cutoff := now.Add(-r.window)
+	i := 0
+	for i < len(r.samples) && r.samples[i].at.Before(cutoff) {
+		i++
+	}
+	r.samples = r.samples[i:]
+}
+
+func (r *rollingRate) perSecond() float64 {
+	if len(r.samples) < 2 {
+		return 0
+	}
+	first, last := r.samples[0], r.samples[len(r.samples)-1]
+	elapsed := last.at.Sub(first.at).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(last.count-first.count) / elapsed
+}
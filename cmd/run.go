@@ -40,11 +40,27 @@ const (
 )
 
 var (
-	yamlFile  string
-	trace     bool
-	skipLimit bool
-	overhead  bool
-	legacy    bool
+	yamlFile     string
+	trace        bool
+	skipLimit    bool
+	overhead     bool
+	legacy       bool
+	outputFormat string
+	outputFile   string
+
+	bootstrapResamples int
+	confidence         float64
+
+	benchTime benchTimeFlag
+
+	flakeRuns int
+
+	baselineFile     string
+	regressThreshold float64
+
+	profileDir   string
+	profileTypes string
+	profileAddr  string
 )
 
 // simple structure to handle collecting output data which will be displayed
@@ -56,6 +72,21 @@ type benchResult struct {
 	iterations  int
 	threadRates []float64
 	statistics  [][]benches.RunStatistics
+	// iterationsPerThread holds each thread count's own actual iteration
+	// count; under --benchtime these can differ per thread count, unlike
+	// the fixed YAML-configured count that `iterations` used to always be.
+	// iterations itself still mirrors the last thread count's value for
+	// callers that only care about the non-legacy, single-thread-count case.
+	iterationsPerThread []int
+	// flakeRuns is the number of passes run (1 by default);
+	// rateCV/rateMin/rateMax/rateMedian are only populated when
+	// --flake > 1; they summarize the distribution of threadRates (one
+	// entry per thread count) across the repeated runs
+	flakeRuns  int
+	rateCV     []float64
+	rateMin    []float64
+	rateMax    []float64
+	rateMedian []float64
 }
 
 // simple structure to handle collecting output data which will be displayed
@@ -64,6 +95,7 @@ type benchSingleResult struct {
 	name       string
 	benchInfo  string
 	driverInfo string
+	iterations int
 	threadRate float64
 	statistic  []benches.RunStatistics
 }
@@ -137,7 +169,32 @@ iterations and number of concurrent threads. Results will be displayed afterward
 		}
 
 		// output benchmark results
-		outputRunDetails(maxThreads, results, overhead, legacy)
+		switch outputFormat {
+		case "json":
+			if err := outputRunDetailsJSON(results, overhead, legacy, outputFile); err != nil {
+				return fmt.Errorf("error writing JSON output: %v", err)
+			}
+		case "csv":
+			if err := outputRunDetailsCSV(results, overhead, legacy, outputFile); err != nil {
+				return fmt.Errorf("error writing CSV output: %v", err)
+			}
+		case "text", "":
+			outputRunDetails(maxThreads, results, overhead, legacy)
+		default:
+			return fmt.Errorf("unknown --output-format %q; must be one of text, json, csv", outputFormat)
+		}
+
+		if baselineFile != "" {
+			baseline, err := loadBaseline(baselineFile)
+			if err != nil {
+				return err
+			}
+			current := buildJSONOutput(results, overhead, legacy)
+			comparisons := compareToBaseline(baseline, current, regressThreshold)
+			if outputBaselineComparison(comparisons) {
+				return fmt.Errorf("performance regression detected against baseline %q", baselineFile)
+			}
+		}
 
 		log.Info("Benchmark runs complete")
 		return nil
@@ -159,12 +216,38 @@ func runLimitTest(ctx context.Context) []float64 {
 	return rates
 }
 
+// runBenchmark runs the given driver's benchmark --flake times (once by
+// default) and aggregates the resulting thread-rate distribution, so CI
+// consumers can distinguish a real regression from run-to-run noise.
 func runBenchmark(ctx context.Context, benchType benches.Type, driverConfig benches.DriverConfig, benchmark benches.Benchmark, legacyMode bool) (benchResult, error) {
+	var passes []benchResult
+	for i := 0; i < intMax(flakeRuns, 1); i++ {
+		pass, err := runBenchmarkPass(ctx, benchType, driverConfig, benchmark, legacyMode, i)
+		if err != nil {
+			return benchResult{}, err
+		}
+		passes = append(passes, pass)
+	}
+
+	result := passes[len(passes)-1]
+	result.flakeRuns = len(passes)
+	if len(passes) > 1 {
+		result.rateCV, result.rateMin, result.rateMax, result.rateMedian = flakeRateStats(passes)
+	}
+	return result, nil
+}
+
+// runBenchmarkPass runs exactly one pass of a driver's benchmark (across
+// all configured thread counts in legacy mode, or the single configured
+// thread count otherwise).
+func runBenchmarkPass(ctx context.Context, benchType benches.Type, driverConfig benches.DriverConfig, benchmark benches.Benchmark, legacyMode bool, pass int) (benchResult, error) {
 	var (
-		rates      []float64
-		stats      [][]benches.RunStatistics
-		benchInfo  string
-		driverInfo string
+		rates               []float64
+		stats               [][]benches.RunStatistics
+		benchInfo           string
+		driverInfo          string
+		iterations          int
+		iterationsPerThread []int
 	)
 
 	if driverConfig.Extended != nil {
@@ -173,46 +256,47 @@ func runBenchmark(ctx context.Context, benchType benches.Type, driverConfig benc
 
 	if legacyMode {
 		stats = make([][]benches.RunStatistics, driverConfig.Threads)
+		iterationsPerThread = make([]int, driverConfig.Threads)
 		// Legacy mode in total run N test suites. for each test suite, it runs with n thread and n is the current thread numbers.
 		for i := 1; i <= driverConfig.Threads; i++ {
-			singleResult, err := runBenchmarkOnce(ctx, benchType, driverConfig, benchmark, i)
+			singleResult, err := runBenchmarkOnce(ctx, benchType, driverConfig, benchmark, i, pass)
 			if err != nil {
 				return benchResult{}, err
 			}
 			benchInfo, driverInfo = singleResult.benchInfo, singleResult.driverInfo
+			iterations = singleResult.iterations
+			iterationsPerThread[i-1] = singleResult.iterations
 			rates = append(rates, singleResult.threadRate)
 			stats[i-1] = singleResult.statistic
 		}
 	} else {
 		stats = make([][]benches.RunStatistics, 1)
-		singleResult, err := runBenchmarkOnce(ctx, benchType, driverConfig, benchmark, driverConfig.Threads)
+		singleResult, err := runBenchmarkOnce(ctx, benchType, driverConfig, benchmark, driverConfig.Threads, pass)
 		if err != nil {
 			return benchResult{}, err
 		}
 		benchInfo, driverInfo = singleResult.benchInfo, singleResult.driverInfo
+		iterations = singleResult.iterations
+		iterationsPerThread = []int{singleResult.iterations}
 		rates = append(rates, singleResult.threadRate)
 		stats[0] = singleResult.statistic
 	}
 
 	result := benchResult{
-		name:        benchInfo,
-		driverInfo:  driverInfo,
-		threads:     driverConfig.Threads,
-		iterations:  driverConfig.Iterations,
-		threadRates: rates,
-		statistics:  stats,
+		name:                benchInfo,
+		driverInfo:          driverInfo,
+		threads:             driverConfig.Threads,
+		iterations:          iterations,
+		iterationsPerThread: iterationsPerThread,
+		threadRates:         rates,
+		statistics:          stats,
 	}
 
 	return result, nil
 }
 
 // runBenchmark run exact one test suite
-func runBenchmarkOnce(ctx context.Context, benchType benches.Type, driverConfig benches.DriverConfig, benchmark benches.Benchmark, threads int) (benchSingleResult, error) {
-	bench, err := benches.New(benchType, &driverConfig)
-	if err != nil {
-		return benchSingleResult{}, err
-	}
-
+func runBenchmarkOnce(ctx context.Context, benchType benches.Type, driverConfig benches.DriverConfig, benchmark benches.Benchmark, threads, pass int) (benchSingleResult, error) {
 	driverType := driver.StringToType(driverConfig.Type)
 	imageInfo := benchmark.Image
 	if driverType == driver.Runc || driverType == driver.Ctr || driverType == driver.CRun || driverType == driver.Youki {
@@ -225,15 +309,96 @@ func runBenchmarkOnce(ctx context.Context, benchType benches.Type, driverConfig
 		imageInfo = benchmark.RootFs
 	}
 
-	err = bench.Init(ctx, benchmark.Name, driverType, driverConfig.ClientPath, imageInfo, benchmark.Command, trace)
-	if err != nil {
-		return benchSingleResult{}, err
+	benchInfo := fmt.Sprintf("%s:%s", benchType, driverConfig.Type)
+
+	// attemptNum distinguishes repeated invocations of attempt below (one
+	// per --benchtime growth step) so each attempt's profile files land in
+	// their own directory instead of overwriting the previous step's
+	attemptNum := 0
+
+	// attempt sets up and runs a fresh bench instance for a single
+	// iteration count; used as-is for a fixed iteration count, or
+	// repeatedly by runBenchtimeLoop when --benchtime is set
+	attempt := func(iterations int) (benches.Bench, time.Duration, error) {
+		profiler, err := newProfileCollector(driverConfig.Type, threads, pass, attemptNum, profileAddr)
+		if err != nil {
+			return nil, 0, err
+		}
+		attemptNum++
+
+		bench, err := benches.New(benchType, &driverConfig)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if err := bench.Init(ctx, benchmark.Name, driverType, driverConfig.ClientPath, imageInfo, benchmark.Command, trace); err != nil {
+			return nil, 0, err
+		}
+
+		if err := bench.Validate(ctx); err != nil {
+			return nil, 0, fmt.Errorf("error during bench validate: %v", err)
+		}
+
+		var (
+			cpuProfileDone   chan struct{}
+			cancelCPUProfile context.CancelFunc
+		)
+		startedAt := time.Now()
+		if profiler != nil {
+			profiler.snapshotHeapAndGoroutine(ctx, "start")
+
+			// the real run duration isn't known until bench.Run
+			// returns, so ask the driver to collect for a generous
+			// upper bound and cancel the request as soon as the run
+			// actually finishes
+			cpuProfileDuration := driverConfig.Duration
+			if cpuProfileDuration <= 0 {
+				cpuProfileDuration = time.Minute
+			}
+			var cpuCtx context.Context
+			cpuCtx, cancelCPUProfile = context.WithCancel(ctx)
+			cpuProfileDone = make(chan struct{})
+			go func() {
+				defer close(cpuProfileDone)
+				profiler.collectCPUProfile(cpuCtx, cpuProfileDuration)
+			}()
+		}
+
+		if err := runWithProgress(ctx, bench, benchInfo, threads, iterations, driverConfig.Duration, benchmark.Commands); err != nil {
+			if cancelCPUProfile != nil {
+				cancelCPUProfile()
+			}
+			return nil, 0, fmt.Errorf("error during bench run: %v", err)
+		}
+
+		duration := bench.Elapsed()
+
+		if profiler != nil {
+			cancelCPUProfile()
+			<-cpuProfileDone
+			profiler.snapshotHeapAndGoroutine(ctx, "end")
+			if err := profiler.writeManifest(startedAt, duration); err != nil {
+				log.Warnf("profile: %v", err)
+			}
+		}
+
+		return bench, duration, nil
 	}
 
-	benchInfo := fmt.Sprintf("%s:%s", benchType, driverConfig.Type)
+	var (
+		bench      benches.Bench
+		duration   time.Duration
+		iterations = driverConfig.Iterations
+		err        error
+	)
 
-	if err = bench.Validate(ctx); err != nil {
-		return benchSingleResult{}, fmt.Errorf("error during bench validate: %v", err)
+	if benchTime.isSet() {
+		iterations, bench, duration, err = runBenchtimeLoop(benchTime, attempt)
+	} else {
+		bench, duration, err = attempt(iterations)
+	}
+	if err != nil {
+		return benchSingleResult{}, err
 	}
 
 	info, err := bench.Info(ctx)
@@ -242,24 +407,18 @@ func runBenchmarkOnce(ctx context.Context, benchType benches.Type, driverConfig
 	}
 
 	driverInfo := info
-
-	err = bench.Run(ctx, threads, driverConfig.Iterations, driverConfig.Duration, benchmark.Commands)
-	if err != nil {
-		return benchSingleResult{}, fmt.Errorf("error during bench run: %v", err)
-	}
-
-	duration := bench.Elapsed()
-	rate := float64(threads*driverConfig.Iterations) / duration.Seconds()
+	rate := float64(threads*iterations) / duration.Seconds()
 
 	result := benchSingleResult{
 		name:       benchInfo,
 		driverInfo: driverInfo,
 		benchInfo:  benchInfo,
+		iterations: iterations,
 		threadRate: rate,
 		statistic:  bench.Stats(),
 	}
 
-	log.Infof("%s: threads %d, iterations %d, rate: %6.2f", benchInfo, threads, driverConfig.Iterations, rate)
+	log.Infof("%s: threads %d, iterations %d, rate: %6.2f", benchInfo, threads, iterations, rate)
 	return result, nil
 }
 
@@ -282,6 +441,9 @@ func outputRunDetails(maxThreads int, results []benchResult, overhead bool, lega
 	for i := 2; i <= maxThreads; i++ {
 		fmt.Fprintf(w, "\t%d thrds", i)
 	}
+	if flakeRuns > 1 {
+		fmt.Fprintf(w, "\t±CV%%")
+	}
 	fmt.Fprintln(w, "\t ")
 
 	for _, result := range results {
@@ -381,7 +543,7 @@ func outputRunDetails(maxThreads int, results []benchResult, overhead bool, lega
 
 func outputDetailCommandStatsLegacy(result benchResult, w *tabwriter.Writer, cmdList []string) {
 	for i := 0; i < result.threads; i++ {
-		fmt.Fprintf(w, "%s:%d\tMin\tMax\tAvg\tMedian\tStddev\tErrors\tCancelled\tRate\t\n", result.name, i+1)
+		fmt.Fprintf(w, "%s:%d\tMin\tMax\tAvg\tMedian\tStddev\t%.4g%% CI\tErrors\tCancelled\tRate\t\n", result.name, i+1, confidence*100)
 		cmdTimings := parseStats(result.statistics[i])
 		nums := 0
 		for _, stat := range result.statistics[i] {
@@ -392,18 +554,22 @@ func outputDetailCommandStatsLegacy(result benchResult, w *tabwriter.Writer, cmd
 		// given we are working with a map, but we want consistent ordering in the output
 		// we walk a slice of commands in a natural/expected order and output stats for
 		// those that were used during the specific run
+		rowIterations := result.iterations
+		if i < len(result.iterationsPerThread) {
+			rowIterations = result.iterationsPerThread[i]
+		}
 		for _, cmd := range cmdList {
 			if stats, ok := cmdTimings[cmd]; ok {
-				fmt.Fprintf(w, "%s\t%6.2f\t%6.2f\t%6.2f\t%6.2f\t%6.2f\t%d\t%d/%d\t%.2f\t\n", cmd, stats.min, stats.max, stats.avg, stats.median, stats.stddev, stats.errors,
-					result.threads*result.iterations-nums, result.threads*result.iterations,
-					((float64)(nums-stats.errors)/float64(result.threads*result.iterations))*100)
+				fmt.Fprintf(w, "%s\t%6.2f\t%6.2f\t%6.2f\t%6.2f\t%6.2f\t[%6.2f, %6.2f]\t%d\t%d/%d\t%.2f\t\n", cmd, stats.min, stats.max, stats.avg, stats.median, stats.stddev, stats.ciLow, stats.ciHigh, stats.errors,
+					result.threads*rowIterations-nums, result.threads*rowIterations,
+					((float64)(nums-stats.errors)/float64(result.threads*rowIterations))*100)
 			}
 		}
 	}
 }
 
 func outputDetailCommandStats(result benchResult, w *tabwriter.Writer, cmdList []string) {
-	fmt.Fprintf(w, "%s:%d\tMin\tMax\tAvg\tMedian\tStddev\tErrors\tCancelled\tRate\t\n", result.name, result.threads)
+	fmt.Fprintf(w, "%s:%d\tMin\tMax\tAvg\tMedian\tStddev\t%.4g%% CI\tErrors\tCancelled\tRate\t\n", result.name, result.threads, confidence*100)
 	cmdTimings := parseStats(result.statistics[0])
 	nums := 0
 	for _, stat := range result.statistics[0] {
@@ -413,7 +579,7 @@ func outputDetailCommandStats(result benchResult, w *tabwriter.Writer, cmdList [
 	}
 	for _, cmd := range cmdList {
 		if stats, ok := cmdTimings[cmd]; ok {
-			fmt.Fprintf(w, "%s\t%6.2f\t%6.2f\t%6.2f\t%6.2f\t%6.2f\t%d\t%d/%d\t%.2f\t\n", cmd, stats.min, stats.max, stats.avg, stats.median, stats.stddev, stats.errors,
+			fmt.Fprintf(w, "%s\t%6.2f\t%6.2f\t%6.2f\t%6.2f\t%6.2f\t[%6.2f, %6.2f]\t%d\t%d/%d\t%.2f\t\n", cmd, stats.min, stats.max, stats.avg, stats.median, stats.stddev, stats.ciLow, stats.ciHigh, stats.errors,
 				result.threads*result.iterations-nums, result.threads*result.iterations,
 				((float64)(nums-stats.errors)/float64(result.threads*result.iterations))*100)
 		}
@@ -430,7 +596,11 @@ func outputThreadRates(w *tabwriter.Writer, result benchResult) {
 	for i := 1; i <= result.threads; i++ {
 		fmt.Fprintf(w, "\t")
 	}
-	fmt.Fprintf(w, "%7.2f\t ", result.threadRates[0])
+	fmt.Fprintf(w, "%7.2f", result.threadRates[0])
+	if flakeRuns > 1 {
+		fmt.Fprintf(w, "\t%s", formatCV(result.rateCV))
+	}
+	fmt.Fprintf(w, "\t ")
 }
 
 func outputThreadRatesLegacy(w *tabwriter.Writer, result benchResult) {
@@ -438,9 +608,26 @@ func outputThreadRatesLegacy(w *tabwriter.Writer, result benchResult) {
 	for i := 1; i < result.threads; i++ {
 		fmt.Fprintf(w, "\t%7.2f", result.threadRates[i])
 	}
+	if flakeRuns > 1 {
+		fmt.Fprintf(w, "\t%s", formatCV(result.rateCV))
+	}
 	fmt.Fprintln(w, "\t ")
 }
 
+// formatCV renders the average coefficient of variation across thread
+// counts as a percentage, or "-" when no flake data is available (e.g. the
+// synthetic "Limit" row, which isn't rerun).
+func formatCV(cv []float64) string {
+	if len(cv) == 0 {
+		return "   -"
+	}
+	var sum float64
+	for _, v := range cv {
+		sum += v
+	}
+	return fmt.Sprintf("%6.2f%%", (sum/float64(len(cv)))*100)
+}
+
 type metricsResults struct {
 	minMem uint64
 	maxMem uint64
@@ -510,6 +697,8 @@ type statResults struct {
 	median float64
 	stddev float64
 	errors int
+	ciLow  float64
+	ciHigh float64
 }
 
 func filterStats(stats []benches.RunStatistics, check func(benches.RunStatistics) bool) (ret []benches.RunStatistics) {
@@ -574,6 +763,7 @@ func parseStats(statistics []benches.RunStatistics) map[string]statResults {
 		if errorSlice, ok := errorSeq[key]; ok {
 			errors = intSum(errorSlice)
 		}
+		ciLow, ciHigh := bcaConfidenceInterval(durationSeq[key], bootstrapResamples, confidence)
 		result[key] = statResults{
 			min:    min,
 			max:    max,
@@ -581,6 +771,8 @@ func parseStats(statistics []benches.RunStatistics) map[string]statResults {
 			median: median,
 			stddev: stddev,
 			errors: errors,
+			ciLow:  ciLow,
+			ciHigh: ciHigh,
 		}
 	}
 	return result
@@ -620,4 +812,15 @@ func init() {
 	runCmd.PersistentFlags().BoolVarP(&skipLimit, "skip-limit", "s", false, "Skip 'limit' benchmark run")
 	runCmd.PersistentFlags().BoolVarP(&overhead, "overhead", "o", false, "Output daemon overhead")
 	runCmd.PersistentFlags().BoolVarP(&legacy, "legacy", "l", false, "legacy mode will run benchmark from 1 to N(thread number) iterations.")
+	runCmd.PersistentFlags().StringVar(&outputFormat, "output-format", "text", "Result output format: text, json, or csv")
+	runCmd.PersistentFlags().StringVar(&outputFile, "output-file", "", "Write results to this file instead of stdout (applies to json/csv formats)")
+	runCmd.PersistentFlags().IntVar(&bootstrapResamples, "bootstrap-resamples", 1000, "Number of bootstrap resamples used to compute confidence intervals on command timings")
+	runCmd.PersistentFlags().Float64Var(&confidence, "confidence", 0.95, "Confidence level for the bootstrap confidence interval on command timings (e.g. 0.95 for 95%)")
+	runCmd.PersistentFlags().Var(&benchTime, "benchtime", "Run each driver benchmark for the given duration (e.g. 10s) or a fixed count (e.g. 1000x) instead of the YAML-configured iteration count")
+	runCmd.PersistentFlags().IntVar(&flakeRuns, "flake", 1, "Rerun each driver benchmark N times and report the coefficient of variation of thread rates across runs")
+	runCmd.PersistentFlags().StringVar(&baselineFile, "baseline", "", "Compare results against a previously saved --output-format json result file and report regressions")
+	runCmd.PersistentFlags().Float64Var(&regressThreshold, "regress-threshold", 5.0, "Percent increase in avg latency over the baseline considered a regression, checked independently of the baseline confidence interval (0 disables)")
+	runCmd.PersistentFlags().StringVar(&profileDir, "profile-dir", "", "Directory to collect pprof profiles from the driver under test into (requires --profile-addr)")
+	runCmd.PersistentFlags().StringVar(&profileTypes, "profile-types", "cpu,heap,goroutine,mutex", "Comma-separated list of pprof profile types to collect: cpu, heap, goroutine, mutex")
+	runCmd.PersistentFlags().StringVar(&profileAddr, "profile-addr", "", "HTTP base URL of the driver's pprof debug endpoint (e.g. http://127.0.0.1:6060); /debug/pprof/ paths are fetched from it over plain HTTP, unix sockets are not supported; required to use --profile-dir")
 }